@@ -0,0 +1,102 @@
+package terraform
+
+import (
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/states"
+	"github.com/hashicorp/terraform/tfdiags"
+)
+
+// ImportOpts are used as the configuration for Import.
+type ImportOpts struct {
+	// Targets are the targets to import
+	Targets []*ImportTarget
+
+	// CollisionMode controls how every target behaves when its address
+	// already has an entry in state. It's copied onto each ImportTarget
+	// before the graph is built, so an ImportTarget.CollisionMode that's
+	// already been set to something other than ImportCollisionUnset wins
+	// over this default.
+	CollisionMode ImportCollisionMode
+
+	// DryRun, if set, previews the import instead of writing it to state.
+	// Every target is imported and read from its provider as usual, but the
+	// resulting attributes are collected into ImportPlans and returned
+	// instead of being persisted. It's copied onto each ImportTarget before
+	// the graph is built, mirroring CollisionMode above.
+	DryRun bool
+}
+
+// ImportTarget is a single resource to import.
+type ImportTarget struct {
+	// Addr is the address for the resource instance that the new object
+	// should be imported into.
+	Addr addrs.AbsResourceInstance
+
+	// ID is the ID of the resource to import. This is resource-specific.
+	ID string
+
+	// ProviderAddr is the address of the provider to use for this import,
+	// if already known. If unset, ImportStateTransformer resolves it from
+	// configuration.
+	ProviderAddr addrs.AbsProviderConfig
+
+	// CollisionMode controls how this target behaves when its address
+	// already has an entry in state. See ImportOpts.CollisionMode.
+	CollisionMode ImportCollisionMode
+
+	// DryRun previews this target's import instead of writing it to state.
+	// See ImportOpts.DryRun.
+	DryRun bool
+}
+
+// Import takes already-created external resources and brings them under
+// Terraform management. Import requires the exact type, name, and ID of the
+// resources to import.
+//
+// If opts.DryRun is set, no state is written: the returned *states.State is
+// nil, and the resources' imported attributes are reported instead via the
+// returned []ImportPlan. If opts.DryRun is unset, the returned []ImportPlan
+// is always nil.
+func (c *Context) Import(opts *ImportOpts) (*states.State, []ImportPlan, tfdiags.Diagnostics) {
+	var diags tfdiags.Diagnostics
+
+	defer c.acquireRun("import")()
+
+	for _, target := range opts.Targets {
+		if target.CollisionMode == ImportCollisionUnset {
+			target.CollisionMode = opts.CollisionMode
+		}
+		if opts.DryRun {
+			target.DryRun = true
+		}
+	}
+
+	plans := &ImportPlans{}
+
+	g := &Graph{Path: addrs.RootModuleInstance}
+
+	t := &ImportStateTransformer{
+		Targets:     opts.Targets,
+		Config:      c.config,
+		ImportPlans: plans,
+	}
+	if err := t.Transform(g); err != nil {
+		diags = diags.Append(err)
+		return nil, nil, diags
+	}
+	if err := (&RootTransformer{}).Transform(g); err != nil {
+		diags = diags.Append(err)
+		return nil, nil, diags
+	}
+
+	_, walkDiags := c.walk(g, walkImport)
+	diags = diags.Append(walkDiags)
+	if diags.HasErrors() {
+		return nil, nil, diags
+	}
+
+	if opts.DryRun {
+		return nil, plans.Plans, diags
+	}
+	return c.state, nil, diags
+}
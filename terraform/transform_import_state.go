@@ -2,41 +2,179 @@ package terraform
 
 import (
 	"fmt"
+	"log"
+	"sync"
 
 	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/configs"
+	"github.com/hashicorp/terraform/providers"
+	"github.com/hashicorp/terraform/states"
 	"github.com/hashicorp/terraform/tfdiags"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // ImportStateTransformer is a GraphTransformer that adds nodes to the
 // graph to represent the imports we want to do for resources.
 type ImportStateTransformer struct {
 	Targets []*ImportTarget
+
+	// Config is the root of the module tree that the targets belong to.
+	// It's used to resolve each target's provider the same way the plan
+	// graph builder does, so that aliased providers and provider "source"
+	// blocks are honored even when the caller hasn't pre-populated
+	// ImportTarget.ProviderAddr. Config may be nil only if every target
+	// already has an explicit ProviderAddr.
+	Config *configs.Config
+
+	// ImportPlans, if non-nil, is the collector that dry-run targets
+	// (ImportTarget.DryRun) append their ImportPlan entries to instead of
+	// writing state. Context.Import always supplies one; only dry-run
+	// targets append to it.
+	ImportPlans *ImportPlans
 }
 
 func (t *ImportStateTransformer) Transform(g *Graph) error {
 	for _, target := range t.Targets {
+		providerAddr, err := t.resolveProviderAddr(target)
+		if err != nil {
+			return err
+		}
+
 		node := &graphNodeImportState{
-			Addr:         target.Addr,
-			ID:           target.ID,
-			ProviderAddr: target.ProviderAddr,
+			Addr:          target.Addr,
+			ID:            target.ID,
+			ProviderAddr:  providerAddr,
+			CollisionMode: target.CollisionMode,
+			DryRun:        target.DryRun,
+			ImportPlans:   t.ImportPlans,
 		}
 		g.Add(node)
 	}
 	return nil
 }
 
+// resolveProviderAddr determines the provider to use to import the given
+// target. If the target already carries an explicit ProviderAddr we trust
+// the caller and use it as-is. Otherwise we descend into t.Config to find
+// the resource's own configuration and derive the provider from there,
+// falling back to the resource type's implied provider when there's no
+// matching configuration (e.g. orphaned imports).
+func (t *ImportStateTransformer) resolveProviderAddr(target *ImportTarget) (addrs.AbsProviderConfig, error) {
+	if !target.ProviderAddr.Provider.IsZero() {
+		return target.ProviderAddr, nil
+	}
+
+	moduleAddr := target.Addr.Module.Module()
+
+	if t.Config == nil {
+		return addrs.AbsProviderConfig{}, fmt.Errorf(
+			"cannot import %s: no configuration is available to resolve its provider", target.Addr,
+		)
+	}
+
+	mc := t.Config.Descendent(moduleAddr)
+	if mc == nil {
+		return addrs.AbsProviderConfig{}, fmt.Errorf(
+			"cannot import %s: %s is not declared in the configuration", target.Addr, moduleAddr,
+		)
+	}
+
+	rc := mc.Module.ResourceByAddr(target.Addr.Resource.Resource)
+	if rc == nil {
+		// No resource block to consult, so fall back to the provider
+		// implied by the resource type name, same as Terraform would use
+		// for a resource with no explicit "provider" argument.
+		return target.Addr.Resource.Resource.DefaultProviderConfig().Absolute(target.Addr.Module), nil
+	}
+
+	return addrs.AbsProviderConfig{
+		Module:   moduleAddr,
+		Provider: rc.Provider,
+		Alias:    rc.ProviderConfigAddr().Alias,
+	}, nil
+}
+
+// ImportCollisionMode controls how graphNodeImportState.DynamicExpand reacts
+// when an imported resource's address already has an entry in state. It's
+// configured via ImportOpts.CollisionMode and threaded through to each
+// ImportTarget so that bulk imports (an ImportState call that legitimately
+// returns many related resources) don't require hand-editing state first.
+type ImportCollisionMode int
+
+const (
+	// ImportCollisionUnset is the zero value, meaning the caller hasn't
+	// chosen a mode for this target. It's distinct from
+	// ImportCollisionError so that ImportOpts.CollisionMode can tell "left
+	// unset, inherit the opts-level default" apart from "explicitly asked
+	// for Error". graphNodeImportState treats it the same as
+	// ImportCollisionError.
+	ImportCollisionUnset ImportCollisionMode = iota
+
+	// ImportCollisionError is the default behavior: any collision aborts
+	// the import with a "Resource already managed by Terraform" diagnostic.
+	ImportCollisionError
+
+	// ImportCollisionSkip drops the colliding address from the import
+	// before the subgraph is expanded, leaving the existing state entry
+	// untouched.
+	ImportCollisionSkip
+
+	// ImportCollisionReplace removes the existing state entry for the
+	// colliding address, under the state lock, before the sub-node that
+	// writes the freshly-imported object runs. In a dry run the existing
+	// entry is left in place.
+	ImportCollisionReplace
+
+	// ImportCollisionRename auto-suffixes the imported resource's name
+	// using the same counter that de-dups names among newly-imported
+	// siblings, but also checks the suffixed name against existing state.
+	ImportCollisionRename
+)
+
+// ImportPlan describes a single resource that a dry-run import walk would
+// write, had ImportOpts.DryRun not been set. It carries everything an
+// operator needs to review the import before committing it: the address it
+// would land at, the type and ID used to fetch it, its refreshed
+// attributes, and any non-fatal warnings the refresh produced.
+type ImportPlan struct {
+	Addr         addrs.AbsResourceInstance
+	ResourceType string
+	ImportedID   string
+	Attributes   cty.Value
+	Warnings     []string
+}
+
+// ImportPlans collects the ImportPlan entries produced during a dry-run
+// import walk. graphNodeImportStateSub nodes for independent resources can
+// run concurrently, so appends are synchronized.
+type ImportPlans struct {
+	mu    sync.Mutex
+	Plans []ImportPlan
+}
+
+// Append records a single ImportPlan entry. It's safe to call from multiple
+// goroutines.
+func (c *ImportPlans) Append(p ImportPlan) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Plans = append(c.Plans, p)
+}
+
 type graphNodeImportState struct {
 	Addr             addrs.AbsResourceInstance // Addr is the resource address to import into
 	ID               string                    // ID is the ID to import as
 	ProviderAddr     addrs.AbsProviderConfig   // Provider address given by the user, or implied by the resource type
 	ResolvedProvider addrs.AbsProviderConfig   // provider node address after resolution
+	CollisionMode    ImportCollisionMode       // how to handle an address that's already in state
+	DryRun           bool                      // if set, don't write state; record an ImportPlan instead
+	ImportPlans      *ImportPlans              // collector for DryRun's ImportPlan entries; only appended to when DryRun is set
 
-	states []*InstanceState
+	importedResources []providers.ImportedResource
 }
 
 var (
 	_ GraphNodeSubPath           = (*graphNodeImportState)(nil)
-	_ GraphNodeEvalable          = (*graphNodeImportState)(nil)
+	_ GraphNodeExecutable        = (*graphNodeImportState)(nil)
 	_ GraphNodeProviderConsumer  = (*graphNodeImportState)(nil)
 	_ GraphNodeDynamicExpandable = (*graphNodeImportState)(nil)
 )
@@ -65,29 +203,28 @@ func (n *graphNodeImportState) Path() addrs.ModuleInstance {
 	return n.Addr.Module
 }
 
-// GraphNodeEvalable impl.
-func (n *graphNodeImportState) EvalTree() EvalNode {
-	var provider ResourceProvider
-	info := NewInstanceInfo(n.Addr)
+// GraphNodeExecutable impl.
+func (n *graphNodeImportState) Execute(ctx EvalContext, op walkOperation) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
 
-	// Reset our states
-	n.states = nil
+	provider, _, err := getProvider(ctx, n.ResolvedProvider)
+	if err != nil {
+		diags = diags.Append(err)
+		return diags
+	}
 
-	// Return our sequence
-	return &EvalSequence{
-		Nodes: []EvalNode{
-			&EvalGetProvider{
-				Addr:   n.ResolvedProvider,
-				Output: &provider,
-			},
-			&EvalImportState{
-				Provider: &provider,
-				Info:     info,
-				Id:       n.ID,
-				Output:   &n.states,
-			},
-		},
+	resp := provider.ImportResourceState(providers.ImportResourceStateRequest{
+		TypeName: n.Addr.Resource.Resource.Type,
+		ID:       n.ID,
+	})
+	diags = diags.Append(resp.Diagnostics)
+	if diags.HasErrors() {
+		return diags
 	}
+
+	log.Printf("[TRACE] graphNodeImportState: import of %s %q produced %d resource(s)", n.Addr, n.ID, len(resp.ImportedResources))
+	n.importedResources = resp.ImportedResources
+	return diags
 }
 
 // GraphNodeDynamicExpandable impl.
@@ -104,17 +241,26 @@ func (n *graphNodeImportState) DynamicExpand(ctx EvalContext) (*Graph, error) {
 	// nameCounter is used to de-dup names in the state.
 	nameCounter := make(map[string]int)
 
-	// Compile the list of addresses that we'll be inserting into the state.
-	// We do this ahead of time so we can verify that we aren't importing
-	// something that already exists.
-	addrs := make([]addrs.AbsResourceInstance, len(n.states))
-	for i, state := range n.states {
+	state := ctx.State().Lock()
+	defer ctx.State().Unlock()
+
+	// Compile the list of addresses and imported resources that we'll
+	// actually add sub-nodes for. We build these up together so that
+	// ImportCollisionSkip and ImportCollisionError can drop entries, while
+	// ImportCollisionRename and ImportCollisionReplace can reconcile the
+	// address against both the other newly-imported siblings and existing
+	// state before the sub-node runs.
+	var keptAddrs []addrs.AbsResourceInstance
+	var keptResources []providers.ImportedResource
+
+	for _, imported := range n.importedResources {
 		addr := n.Addr
-		if t := state.Ephemeral.Type; t != "" {
-			addr.Resource.Resource.Type = t
+		if imported.TypeName != "" {
+			addr.Resource.Resource.Type = imported.TypeName
 		}
 
-		// Determine if we need to suffix the name to de-dup
+		// Determine if we need to suffix the name to de-dup against other
+		// newly-imported resources in this same batch.
 		key := addr.String()
 		count, ok := nameCounter[key]
 		if ok {
@@ -123,49 +269,56 @@ func (n *graphNodeImportState) DynamicExpand(ctx EvalContext) (*Graph, error) {
 		}
 		nameCounter[key] = count
 
-		// Add it to our list
-		addrs[i] = addr
-	}
-
-	// Verify that all the addresses are clear
-	state, lock := ctx.State()
-	lock.RLock()
-	defer lock.RUnlock()
-	filter := &StateFilter{State: state}
-	for _, addr := range addrs {
-		result, err := filter.Filter(addr.String())
-		if err != nil {
-			diags = diags.Append(fmt.Errorf("Error while checking for existing %s in state: %s", addr, err))
-			continue
-		}
+		if existing := state.ResourceInstance(addr); existing != nil {
+			switch n.CollisionMode {
+			case ImportCollisionSkip:
+				log.Printf("[DEBUG] graphNodeImportState: skipping %s, already managed by Terraform", addr)
+				continue
 
-		// Go through the filter results and it is an error if we find
-		// a matching InstanceState, meaning that we would have a collision.
-		for _, r := range result {
-			if is, ok := r.Value.(*InstanceState); ok {
+			case ImportCollisionReplace:
+				log.Printf("[DEBUG] graphNodeImportState: replacing existing state for %s", addr)
+				if !n.DryRun {
+					state.Module(addr.Module).SetResourceInstanceCurrent(addr.Resource, nil, n.ResolvedProvider)
+				}
+
+			case ImportCollisionRename:
+				base := addr.Resource.Resource.Name
+				for state.ResourceInstance(addr) != nil {
+					count++
+					addr.Resource.Resource.Name = fmt.Sprintf("%s-%d", base, count)
+				}
+				nameCounter[key] = count
+
+			default: // ImportCollisionUnset, ImportCollisionError
 				diags = diags.Append(tfdiags.Sourceless(
 					tfdiags.Error,
 					"Resource already managed by Terraform",
-					fmt.Sprintf("Terraform is already managing a remote object for %s, with the id %q. To import to this address you must first remove the existing object from the state.", addr, is.ID),
+					fmt.Sprintf("Terraform is already managing a remote object for %s. To import to this address you must first remove the existing object from the state.", addr),
 				))
 				continue
 			}
 		}
+
+		keptAddrs = append(keptAddrs, addr)
+		keptResources = append(keptResources, imported)
 	}
 	if diags.HasErrors() {
 		// Bail out early, then.
 		return nil, diags.Err()
 	}
 
-	// For each of the states, we add a node to handle the refresh/add to state.
-	// "n.states" is populated by our own EvalTree with the result of
-	// ImportState. Since DynamicExpand is always called after EvalTree, this
-	// is safe.
-	for i, state := range n.states {
+	// For each of the imported resources, we add a node to handle the
+	// refresh/add to state. "n.importedResources" is populated by our own
+	// Execute with the result of ImportResourceState. Since DynamicExpand is
+	// always called after Execute, this is safe.
+	for i, imported := range keptResources {
 		g.Add(&graphNodeImportStateSub{
-			TargetAddr:       addrs[i],
-			State:            state,
+			TargetAddr:       keptAddrs[i],
+			ImportID:         n.ID,
+			State:            imported,
 			ResolvedProvider: n.ResolvedProvider,
+			DryRun:           n.DryRun,
+			ImportPlans:      n.ImportPlans,
 		})
 	}
 
@@ -184,62 +337,108 @@ func (n *graphNodeImportState) DynamicExpand(ctx EvalContext) (*Graph, error) {
 // and adding a resource to the state once it is imported.
 type graphNodeImportStateSub struct {
 	TargetAddr       addrs.AbsResourceInstance
-	State            *InstanceState
+	ImportID         string // the ID originally given to import this resource, for ImportPlan reporting
+	State            providers.ImportedResource
 	ResolvedProvider addrs.AbsProviderConfig
+	DryRun           bool         // if set, skip the state write and record an ImportPlan instead
+	ImportPlans      *ImportPlans // where to record the ImportPlan when DryRun is set
 }
 
 var (
-	_ GraphNodeSubPath  = (*graphNodeImportStateSub)(nil)
-	_ GraphNodeEvalable = (*graphNodeImportStateSub)(nil)
+	_ GraphNodeSubPath    = (*graphNodeImportStateSub)(nil)
+	_ GraphNodeExecutable = (*graphNodeImportStateSub)(nil)
 )
 
 func (n *graphNodeImportStateSub) Name() string {
-	return fmt.Sprintf("import %s result: %s", n.TargetAddr, n.State.ID)
+	return fmt.Sprintf("import %s result: %s", n.TargetAddr, n.State.TypeName)
 }
 
 func (n *graphNodeImportStateSub) Path() addrs.ModuleInstance {
 	return n.TargetAddr.Module
 }
 
-// GraphNodeEvalable impl.
-func (n *graphNodeImportStateSub) EvalTree() EvalNode {
-	// If the Ephemeral type isn't set, then it is an error
-	if n.State.Ephemeral.Type == "" {
-		err := fmt.Errorf("import of %s didn't set type for %q", n.TargetAddr.String(), n.State.ID)
-		return &EvalReturnError{Error: &err}
-	}
-
-	// DeepCopy so we're only modifying our local copy
-	state := n.State.DeepCopy()
-
-	// Key is the resource key
-	key := NewLegacyResourceInstanceAddress(n.TargetAddr).stateId()
-
-	// The eval sequence
-	var provider ResourceProvider
-	return &EvalSequence{
-		Nodes: []EvalNode{
-			&EvalGetProvider{
-				Addr:   n.ResolvedProvider,
-				Output: &provider,
-			},
-			&EvalRefresh{
-				Addr:     n.TargetAddr.Resource,
-				Provider: &provider,
-				State:    &state,
-				Output:   &state,
-			},
-			&EvalImportStateVerify{
-				Addr:  n.TargetAddr.Resource,
-				Id:    n.State.ID,
-				State: &state,
-			},
-			&EvalWriteState{
-				Name:         key,
-				ResourceType: n.TargetAddr.Resource.Resource.Type,
-				Provider:     n.ResolvedProvider,
-				State:        &state,
-			},
-		},
+// GraphNodeExecutable impl.
+//
+// This refreshes the freshly-imported resource, verifies the provider
+// didn't hand back something unusable, and writes the result into state,
+// all inline rather than as a separate eval sequence. It no longer uses the
+// EvalImportState, EvalRefresh, EvalImportStateVerify, and EvalWriteState
+// nodes; eval_import_state.go, which defines EvalImportState and
+// EvalImportStateVerify, is still in the tree.
+func (n *graphNodeImportStateSub) Execute(ctx EvalContext, op walkOperation) tfdiags.Diagnostics {
+	var diags tfdiags.Diagnostics
+
+	// If the type isn't set, then it is an error
+	if n.State.TypeName == "" {
+		diags = diags.Append(fmt.Errorf("import of %s didn't set a resource type", n.TargetAddr.String()))
+		return diags
+	}
+
+	provider, providerSchema, err := getProvider(ctx, n.ResolvedProvider)
+	if err != nil {
+		diags = diags.Append(err)
+		return diags
 	}
+
+	schema, schemaVersion := providerSchema.SchemaForResourceType(addrs.ManagedResourceMode, n.State.TypeName)
+	if schema == nil {
+		diags = diags.Append(fmt.Errorf("provider %s does not support resource type %q", n.ResolvedProvider, n.State.TypeName))
+		return diags
+	}
+
+	readResp := provider.ReadResource(providers.ReadResourceRequest{
+		TypeName:   n.State.TypeName,
+		PriorState: n.State.State,
+		Private:    n.State.Private,
+	})
+	diags = diags.Append(readResp.Diagnostics)
+	if diags.HasErrors() {
+		return diags
+	}
+
+	if readResp.NewState.IsNull() {
+		diags = diags.Append(tfdiags.Sourceless(
+			tfdiags.Error,
+			"Cannot import non-existent remote object",
+			fmt.Sprintf("While attempting to import an existing object to %s, the provider detected that no object exists with the given id. Only pre-existing objects can be imported.", n.TargetAddr),
+		))
+		return diags
+	}
+
+	var warnings []string
+	for _, d := range readResp.Diagnostics {
+		if d.Severity() == tfdiags.Warning {
+			warnings = append(warnings, d.Description().Summary)
+		}
+	}
+
+	if n.DryRun {
+		// Don't touch state at all; just report what we would have
+		// written so the caller can preview it.
+		n.ImportPlans.Append(ImportPlan{
+			Addr:         n.TargetAddr,
+			ResourceType: n.State.TypeName,
+			ImportedID:   n.ImportID,
+			Attributes:   readResp.NewState,
+			Warnings:     warnings,
+		})
+		return diags
+	}
+
+	obj := &states.ResourceInstanceObject{
+		Value:   readResp.NewState,
+		Private: readResp.Private,
+		Status:  states.ObjectReady,
+	}
+	src, err := obj.Encode(schema.ImpliedType(), schemaVersion)
+	if err != nil {
+		diags = diags.Append(fmt.Errorf("failed to encode imported state for %s: %s", n.TargetAddr, err))
+		return diags
+	}
+
+	state := ctx.State().Lock()
+	state.EnsureModule(n.TargetAddr.Module).SetResourceInstanceCurrent(n.TargetAddr.Resource, src, n.ResolvedProvider)
+	ctx.State().Unlock()
+
+	return diags
 }
@@ -0,0 +1,123 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+)
+
+func TestImportStateTransformer_resolveProviderAddr(t *testing.T) {
+	mod := testModule(t, "transform-import-state-provider")
+
+	addr := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "aws_instance",
+		Name: "foo",
+	}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+
+	t.Run("explicit ProviderAddr short-circuits config lookup", func(t *testing.T) {
+		tr := &ImportStateTransformer{}
+		target := &ImportTarget{
+			Addr:         addr,
+			ProviderAddr: addrs.AbsProviderConfig{Module: addrs.RootModule, Provider: addrs.NewLegacyProvider("aws"), Alias: "explicit"},
+		}
+
+		got, err := tr.resolveProviderAddr(target)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got.Alias != "explicit" {
+			t.Fatalf("expected the explicit ProviderAddr to pass through untouched, got %#v", got)
+		}
+	})
+
+	t.Run("nil Config is an error", func(t *testing.T) {
+		tr := &ImportStateTransformer{}
+		target := &ImportTarget{Addr: addr}
+
+		if _, err := tr.resolveProviderAddr(target); err == nil {
+			t.Fatal("expected an error when Config is nil")
+		}
+	})
+
+	t.Run("module not found in config is an error", func(t *testing.T) {
+		tr := &ImportStateTransformer{Config: mod}
+		target := &ImportTarget{
+			Addr: addrs.Resource{
+				Mode: addrs.ManagedResourceMode,
+				Type: "aws_instance",
+				Name: "foo",
+			}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance.Child("missing", addrs.NoKey)),
+		}
+
+		if _, err := tr.resolveProviderAddr(target); err == nil {
+			t.Fatal("expected an error when the target's module isn't declared in the config")
+		}
+	})
+
+	t.Run("resource found in config derives provider and alias", func(t *testing.T) {
+		tr := &ImportStateTransformer{Config: mod}
+		target := &ImportTarget{Addr: addr}
+
+		got, err := tr.resolveProviderAddr(target)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got.Alias != "west" {
+			t.Fatalf("expected the resource's configured alias %q, got %q", "west", got.Alias)
+		}
+	})
+
+	t.Run("no matching resource block falls back to the implied provider", func(t *testing.T) {
+		tr := &ImportStateTransformer{Config: mod}
+		target := &ImportTarget{
+			Addr: addrs.Resource{
+				Mode: addrs.ManagedResourceMode,
+				Type: "aws_instance",
+				Name: "orphan",
+			}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance),
+		}
+
+		got, err := tr.resolveProviderAddr(target)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if got.Provider.Type != "aws" {
+			t.Fatalf("expected the implied aws provider, got %#v", got.Provider)
+		}
+	})
+}
+
+func TestImportStateTransformer_Transform(t *testing.T) {
+	mod := testModule(t, "transform-import-state-provider")
+
+	addr := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "aws_instance",
+		Name: "foo",
+	}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+
+	tr := &ImportStateTransformer{
+		Config: mod,
+		Targets: []*ImportTarget{
+			{Addr: addr, ID: "i-123"},
+		},
+	}
+
+	g := &Graph{Path: addrs.RootModuleInstance}
+	if err := tr.Transform(g); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	vs := g.Vertices()
+	if len(vs) != 1 {
+		t.Fatalf("expected 1 vertex, got %d", len(vs))
+	}
+	n, ok := vs[0].(*graphNodeImportState)
+	if !ok {
+		t.Fatalf("expected a *graphNodeImportState vertex, got %T", vs[0])
+	}
+	if got, want := n.ProviderAddr.Alias, "west"; got != want {
+		t.Fatalf("wrong resolved provider alias: got %q, want %q", got, want)
+	}
+}
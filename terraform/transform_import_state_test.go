@@ -0,0 +1,216 @@
+package terraform
+
+import (
+	"testing"
+
+	"github.com/hashicorp/terraform/addrs"
+	"github.com/hashicorp/terraform/providers"
+	"github.com/hashicorp/terraform/states"
+	"github.com/zclconf/go-cty/cty"
+)
+
+func TestGraphNodeImportStateExecute(t *testing.T) {
+	p := testProvider("aws")
+	p.ImportResourceStateResponse = providers.ImportResourceStateResponse{
+		ImportedResources: []providers.ImportedResource{
+			{
+				TypeName: "aws_instance",
+				State:    cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal("foo")}),
+			},
+		},
+	}
+
+	addr := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "aws_instance",
+		Name: "foo",
+	}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+
+	n := &graphNodeImportState{
+		Addr:             addr,
+		ID:               "foo",
+		ResolvedProvider: addrs.AbsProviderConfig{Module: addrs.RootModule, Provider: addrs.NewLegacyProvider("aws")},
+	}
+
+	ctx := &MockEvalContext{ProviderProvider: p}
+	diags := n.Execute(ctx, walkImport)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+
+	if len(n.importedResources) != 1 {
+		t.Fatalf("expected 1 imported resource, got %d", len(n.importedResources))
+	}
+	if got, want := n.importedResources[0].TypeName, "aws_instance"; got != want {
+		t.Fatalf("wrong type name: got %q, want %q", got, want)
+	}
+}
+
+func TestGraphNodeImportStateSubExecute_typeNotSet(t *testing.T) {
+	addr := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "aws_instance",
+		Name: "foo",
+	}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+
+	n := &graphNodeImportStateSub{
+		TargetAddr: addr,
+		State:      providers.ImportedResource{},
+	}
+
+	ctx := &MockEvalContext{}
+	diags := n.Execute(ctx, walkImport)
+	if !diags.HasErrors() {
+		t.Fatal("expected an error when the imported resource's type isn't set")
+	}
+}
+
+func TestGraphNodeImportState_DynamicExpandCollisions(t *testing.T) {
+	addr := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "aws_instance",
+		Name: "foo",
+	}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+	provider := addrs.AbsProviderConfig{Module: addrs.RootModule, Provider: addrs.NewLegacyProvider("aws")}
+
+	newStateWithCollision := func() *states.State {
+		s := states.NewState()
+		s.Module(addrs.RootModuleInstance).SetResourceInstanceCurrent(
+			addr.Resource,
+			&states.ResourceInstanceObjectSrc{Status: states.ObjectReady, AttrsJSON: []byte(`{"id":"bar"}`)},
+			provider,
+		)
+		return s
+	}
+
+	imported := []providers.ImportedResource{
+		{TypeName: "aws_instance", State: cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal("bar")})},
+	}
+
+	t.Run("skip", func(t *testing.T) {
+		n := &graphNodeImportState{
+			Addr: addr, ID: "bar", ResolvedProvider: provider,
+			CollisionMode:     ImportCollisionSkip,
+			importedResources: imported,
+		}
+		ctx := &MockEvalContext{StateState: newStateWithCollision().SyncWrapper()}
+		g, err := n.DynamicExpand(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		subs := 0
+		for _, v := range g.Vertices() {
+			if _, ok := v.(*graphNodeImportStateSub); ok {
+				subs++
+			}
+		}
+		if subs != 0 {
+			t.Fatalf("expected no sub-nodes for a skipped collision, got %d", subs)
+		}
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		n := &graphNodeImportState{
+			Addr: addr, ID: "bar", ResolvedProvider: provider,
+			CollisionMode:     ImportCollisionRename,
+			importedResources: imported,
+		}
+		ctx := &MockEvalContext{StateState: newStateWithCollision().SyncWrapper()}
+		g, err := n.DynamicExpand(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		vs := g.Vertices()
+		if len(vs) != 1 {
+			t.Fatalf("expected exactly one renamed sub-node, got %d", len(vs))
+		}
+		sub, ok := vs[0].(*graphNodeImportStateSub)
+		if !ok {
+			t.Fatalf("expected a *graphNodeImportStateSub vertex, got %T", vs[0])
+		}
+		if sub.TargetAddr.Resource.Resource.Name == addr.Resource.Resource.Name {
+			t.Fatal("expected the colliding resource to be renamed")
+		}
+	})
+
+	t.Run("replace", func(t *testing.T) {
+		n := &graphNodeImportState{
+			Addr: addr, ID: "bar", ResolvedProvider: provider,
+			CollisionMode:     ImportCollisionReplace,
+			importedResources: imported,
+		}
+		state := newStateWithCollision()
+		ctx := &MockEvalContext{StateState: state.SyncWrapper()}
+		g, err := n.DynamicExpand(ctx)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		vs := g.Vertices()
+		if len(vs) != 1 {
+			t.Fatalf("expected exactly one sub-node, got %d", len(vs))
+		}
+		sub, ok := vs[0].(*graphNodeImportStateSub)
+		if !ok {
+			t.Fatalf("expected a *graphNodeImportStateSub vertex, got %T", vs[0])
+		}
+		if sub.TargetAddr.Resource.Resource.Name != addr.Resource.Resource.Name {
+			t.Fatal("replace mode should reuse the original address rather than renaming it")
+		}
+		if existing := state.ResourceInstance(addr); existing != nil {
+			t.Fatal("expected the existing state entry to have been removed before the sub-node runs")
+		}
+	})
+
+	t.Run("error (default)", func(t *testing.T) {
+		n := &graphNodeImportState{
+			Addr: addr, ID: "bar", ResolvedProvider: provider,
+			importedResources: imported,
+		}
+		ctx := &MockEvalContext{StateState: newStateWithCollision().SyncWrapper()}
+		_, err := n.DynamicExpand(ctx)
+		if err == nil {
+			t.Fatal("expected an error for a collision in the default (Error) mode")
+		}
+	})
+}
+
+func TestGraphNodeImportStateSubExecute_dryRun(t *testing.T) {
+	p := testProvider("aws")
+
+	addr := addrs.Resource{
+		Mode: addrs.ManagedResourceMode,
+		Type: "aws_instance",
+		Name: "foo",
+	}.Instance(addrs.NoKey).Absolute(addrs.RootModuleInstance)
+
+	plans := &ImportPlans{}
+
+	n := &graphNodeImportStateSub{
+		TargetAddr: addr,
+		ImportID:   "bar",
+		State: providers.ImportedResource{
+			TypeName: "aws_instance",
+			State:    cty.ObjectVal(map[string]cty.Value{"id": cty.StringVal("bar")}),
+		},
+		ResolvedProvider: addrs.AbsProviderConfig{Module: addrs.RootModule, Provider: addrs.NewLegacyProvider("aws")},
+		DryRun:           true,
+		ImportPlans:      plans,
+	}
+
+	ctx := &MockEvalContext{ProviderProvider: p, ProviderSchemaSchema: p.GetSchemaReturn, StateState: states.NewState().SyncWrapper()}
+	diags := n.Execute(ctx, walkImport)
+	if diags.HasErrors() {
+		t.Fatalf("unexpected errors: %s", diags.Err())
+	}
+
+	if len(plans.Plans) != 1 {
+		t.Fatalf("expected 1 import plan, got %d", len(plans.Plans))
+	}
+	if got, want := plans.Plans[0].ImportedID, "bar"; got != want {
+		t.Fatalf("wrong imported ID: got %q, want %q", got, want)
+	}
+
+	if existing := ctx.StateState.ResourceInstance(addr); existing != nil {
+		t.Fatal("dry-run should not write the imported resource into state")
+	}
+}